@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -17,6 +18,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/sts"
 	fs4s "github.com/pJes2/fs4/fs4strings"
 )
 
@@ -34,7 +36,10 @@ type Uploader struct {
 	client   *s3.S3
 }
 
-// BBU represents struct responsible for Browser Based Uploads.
+// BBU represents struct responsible for Browser Based Uploads. Its credential fields are
+// a snapshot taken at NewBBU time, so a single BBU stays internally consistent (its
+// AccessKey, secret and session token all come from the same credential refresh) even if
+// the underlying S3Config's assumed-role credentials rotate afterward.
 type BBU struct {
 	Conditions      Conditions
 	DateStringISO   string
@@ -43,34 +48,40 @@ type BBU struct {
 	minutesToExpiry int
 	expiration      string
 	dateString      string
+	accessKey       string
 	secretKey       string
+	sessionToken    string
 	region          string
 }
 
 // Conditions represents BBU conditions slice used to calculate policy and signature.
-type Conditions []map[string]string
+// Each entry is either a flat equality map (`{"key":"uploads/abc.jpg"}`) or an
+// array-form matcher (`["eq", "$key", "..."]`, `["starts-with", "$key", "uploads/"]`,
+// `["content-length-range", 1024, 10485760]`) as accepted by S3 POST policies.
+type Conditions []interface{}
 
 // bbuParams represents a struct from which base64 policy and signature are constructed.
 type bbuParams struct {
-	Conditions []map[string]string `json:"conditions"`
-	Expiration string              `json:"expiration"`
-	SecretKey  string              `json:"-"`
-	Policy64   string              `json:"-"`
-	Region     string              `json:"-"`
-	Date       string              `json:"-"`
+	Conditions Conditions `json:"conditions"`
+	Expiration string     `json:"expiration"`
+	SecretKey  string     `json:"-"`
+	Policy64   string     `json:"-"`
+	Region     string     `json:"-"`
+	Date       string     `json:"-"`
 }
 
 // BBUResponse represents a struct with data required to fill the html upload form fields.
 type BBUResponse struct {
-	URL         string `json:"url"`
-	RedirectURI string `json:"success_action_redirect"`
-	Algorithm   string `json:"x_amz_algorithm"`
-	Credential  string `json:"x_amz_credential"`
-	AccessKey   string `json:"aws_access_key_id"`
-	Signature   string `json:"signature"`
-	Policy      string `json:"policy"`
-	Date        string `json:"x_amz_date"`
-	Key         string `json:"key"`
+	URL           string `json:"url"`
+	RedirectURI   string `json:"success_action_redirect"`
+	Algorithm     string `json:"x_amz_algorithm"`
+	Credential    string `json:"x_amz_credential"`
+	AccessKey     string `json:"aws_access_key_id"`
+	Signature     string `json:"signature"`
+	Policy        string `json:"policy"`
+	Date          string `json:"x_amz_date"`
+	Key           string `json:"key"`
+	SecurityToken string `json:"x_amz_security_token,omitempty"`
 }
 
 // S3Config represents user's s3 application configuration.
@@ -80,6 +91,36 @@ type S3Config struct {
 	Bucket     string
 	Region     string
 	Accelerate bool
+
+	// Endpoint, when set, targets an S3-compatible service (MinIO, SeaweedFS,
+	// DigitalOcean Spaces, Wasabi, LocalStack, ...) instead of AWS S3.
+	Endpoint string
+	// PathStyle addresses the bucket as a path on Endpoint (https://endpoint/bucket/)
+	// instead of a virtual-host subdomain (https://bucket.endpoint/). Only applies
+	// when Endpoint is set.
+	PathStyle bool
+	// DisableSSL forces plain HTTP against Endpoint. Only applies when Endpoint is set.
+	DisableSSL bool
+
+	// SessionToken is the STS session token for temporary credentials. When set, it is
+	// used when building s3.S3 clients and is also carried as an x-amz-security-token
+	// BBU condition so browser POSTs present it to S3.
+	SessionToken string
+
+	assumeRole *assumeRoleConfig
+}
+
+// assumeRoleConfig holds the parameters and refreshable state for credentials obtained
+// via NewClientFromAssumeRole. mu serializes refreshes against the S3Config's credential
+// fields so concurrent callers (e.g. two goroutines minting BBUs around an expiry
+// boundary) never observe a half-updated credential set.
+type assumeRoleConfig struct {
+	mu sync.Mutex
+
+	roleARN     string
+	sessionName string
+	duration    time.Duration
+	expiresAt   time.Time
 }
 
 // NewClient returns new FS4 object initialized with s3Config.
@@ -89,6 +130,78 @@ func NewClient(s3Config *S3Config) *FS4 {
 	}
 }
 
+// NewClientFromAssumeRole assumes roleARN via STS and returns a new FS4 object whose
+// credentials are refreshed on demand: each time a BBU, Uploader or Presigner is built
+// within a minute of the credentials' expiry (or after it), STS AssumeRole is called
+// again first. Nothing refreshes them in the background, and an Uploader or Presigner
+// that is already built keeps the static credentials it was built with. duration is the
+// requested STS session duration; fields already set on baseCfg (Region, Bucket,
+// Endpoint, ...) are preserved.
+func NewClientFromAssumeRole(baseCfg *S3Config, roleARN, sessionName string, duration time.Duration) (*FS4, error) {
+	cfg := *baseCfg
+	cfg.assumeRole = &assumeRoleConfig{
+		roleARN:     roleARN,
+		sessionName: sessionName,
+		duration:    duration,
+	}
+
+	if err := refreshAssumedRole(&cfg); err != nil {
+		return nil, err
+	}
+
+	return NewClient(&cfg), nil
+}
+
+// snapshotCredentials returns a consistent view of cfg's access key, secret key, session
+// token and region, refreshing assumed-role credentials first if they are missing or
+// about to expire. The check, refresh and read happen under the same lock so concurrent
+// callers always see a credential set that came from the same refresh.
+func (cfg *S3Config) snapshotCredentials() (accessKey, secretKey, sessionToken, region string) {
+	if cfg.assumeRole == nil {
+		return cfg.AccessKey, cfg.SecretKey, cfg.SessionToken, cfg.Region
+	}
+
+	cfg.assumeRole.mu.Lock()
+	defer cfg.assumeRole.mu.Unlock()
+
+	if time.Now().After(cfg.assumeRole.expiresAt.Add(-time.Minute)) {
+		// Best effort: fall back to the existing (possibly stale) credentials on error.
+		refreshAssumedRoleLocked(cfg)
+	}
+
+	return cfg.AccessKey, cfg.SecretKey, cfg.SessionToken, cfg.Region
+}
+
+// refreshAssumedRole calls STS AssumeRole and updates cfg's AccessKey, SecretKey,
+// SessionToken and credential expiry in place, serialized on cfg.assumeRole.mu.
+func refreshAssumedRole(cfg *S3Config) error {
+	cfg.assumeRole.mu.Lock()
+	defer cfg.assumeRole.mu.Unlock()
+
+	return refreshAssumedRoleLocked(cfg)
+}
+
+// refreshAssumedRoleLocked is refreshAssumedRole's body; callers must hold cfg.assumeRole.mu.
+func refreshAssumedRoleLocked(cfg *S3Config) error {
+	svc := sts.New(session.New(), aws.NewConfig().WithRegion(cfg.Region))
+
+	resp, err := svc.AssumeRole(&sts.AssumeRoleInput{
+		RoleArn:         aws.String(cfg.assumeRole.roleARN),
+		RoleSessionName: aws.String(cfg.assumeRole.sessionName),
+		DurationSeconds: aws.Int64(int64(cfg.assumeRole.duration.Seconds())),
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.AccessKey = *resp.Credentials.AccessKeyId
+	cfg.SecretKey = *resp.Credentials.SecretAccessKey
+	cfg.SessionToken = *resp.Credentials.SessionToken
+	cfg.assumeRole.expiresAt = *resp.Credentials.Expiration
+
+	return nil
+}
+
 // UploadFile uploads provided file to s3 bucket.
 func (u *Uploader) UploadFile(key, mimetype string, body io.Reader, expiry time.Duration) (string, error) {
 	if _, err := u.uploader.Upload(&s3manager.UploadInput{
@@ -157,21 +270,36 @@ func HeadS3Object(key string, s3Config *S3Config) {
 
 // prepareSVC returns s3.S3 object configured with data from s3Config.
 func prepareSVC(s3Config *S3Config) *s3.S3 {
-	creds := credentials.NewStaticCredentials(s3Config.AccessKey, s3Config.SecretKey, "")
+	accessKey, secretKey, sessionToken, _ := s3Config.snapshotCredentials()
+
+	creds := credentials.NewStaticCredentials(accessKey, secretKey, sessionToken)
 	cfg := aws.NewConfig().WithRegion(s3Config.Region).WithCredentials(creds)
+
+	if s3Config.Endpoint != "" {
+		cfg = cfg.WithEndpoint(s3Config.Endpoint).
+			WithS3ForcePathStyle(s3Config.PathStyle).
+			WithDisableSSL(s3Config.DisableSSL)
+	}
+
 	return s3.New(session.New(), cfg)
 }
 
 // NewBBU sets to fs4.BBU and returns new BBU object initialized with number of minutes until the object expires.
 func (fs4 *FS4) NewBBU(minutesToExpiry int) *BBU {
+	accessKey, secretKey, sessionToken, region := fs4.config.snapshotCredentials()
+
 	now := time.Now()
 	dateString := dateString(now)
 	fs4.BBU = &BBU{
 		config:        fs4.config,
 		dateString:    dateString,
 		DateStringISO: dateStringISO(dateString),
-		Credential:    fs4.config.credential(dateString),
+		Credential:    buildCredential(accessKey, dateString, region),
 		expiration:    expirationDate(now, minutesToExpiry),
+		accessKey:     accessKey,
+		secretKey:     secretKey,
+		sessionToken:  sessionToken,
+		region:        region,
 	}
 
 	fs4.BBU.setDefaultConditions()
@@ -181,7 +309,7 @@ func (fs4 *FS4) NewBBU(minutesToExpiry int) *BBU {
 
 // setDefaultConditions sets condition required to create policy and by HTML form.
 func (bbu *BBU) setDefaultConditions() *BBU {
-	bbu.Conditions = []map[string]string{
+	bbu.Conditions = Conditions{
 		map[string]string{
 			fs4s.Bucket: bbu.config.Bucket,
 		},
@@ -196,16 +324,43 @@ func (bbu *BBU) setDefaultConditions() *BBU {
 		},
 	}
 
+	if bbu.sessionToken != "" {
+		bbu.Conditions = append(bbu.Conditions, map[string]string{
+			fs4s.XAMZSecurityToken: bbu.sessionToken,
+		})
+	}
+
 	return bbu
 }
 
-// AddCondition adds a key-value condition to Conditions slice.
+// AddCondition adds a key-value equality condition to Conditions slice.
 func (bbu *BBU) AddCondition(key, value string) *BBU {
 	bbu.Conditions = append(bbu.Conditions, map[string]string{key: value})
 
 	return bbu
 }
 
+// AddEq adds an array-form equality condition (`["eq", "$field", value]`) to Conditions slice.
+func (bbu *BBU) AddEq(field, value string) *BBU {
+	bbu.Conditions = append(bbu.Conditions, []interface{}{"eq", "$" + field, value})
+
+	return bbu
+}
+
+// AddStartsWith adds a prefix-match condition (`["starts-with", "$field", prefix]`) to Conditions slice.
+func (bbu *BBU) AddStartsWith(field, prefix string) *BBU {
+	bbu.Conditions = append(bbu.Conditions, []interface{}{"starts-with", "$" + field, prefix})
+
+	return bbu
+}
+
+// AddContentLengthRange adds a content-length-range condition (`["content-length-range", min, max]`) to Conditions slice.
+func (bbu *BBU) AddContentLengthRange(min, max int64) *BBU {
+	bbu.Conditions = append(bbu.Conditions, []interface{}{"content-length-range", min, max})
+
+	return bbu
+}
+
 // FormFields returns JSON response required to fill the html upload form fields.
 func (bbu *BBU) FormFields() ([]byte, error) {
 	bbuParams := bbu.toParams()
@@ -214,24 +369,29 @@ func (bbu *BBU) FormFields() ([]byte, error) {
 	key := bbu.conditionForKey(fs4s.Key)
 
 	bbuResponse := &BBUResponse{
-		URL:         bbu.bucketURL(),
-		RedirectURI: redirectURI,
-		AccessKey:   bbu.config.AccessKey,
-		Algorithm:   fs4s.AWS4HmacSha256,
-		Credential:  bbu.config.credential(bbu.dateString),
-		Key:         key,
-		Date:        bbu.DateStringISO,
-		Policy:      bbuParams.toPolicy(),
-		Signature:   bbuParams.toSignature(),
+		URL:           bbu.bucketURL(),
+		RedirectURI:   redirectURI,
+		AccessKey:     bbu.accessKey,
+		Algorithm:     fs4s.AWS4HmacSha256,
+		Credential:    bbu.Credential,
+		Key:           key,
+		Date:          bbu.DateStringISO,
+		Policy:        bbuParams.toPolicy(),
+		Signature:     bbuParams.toSignature(),
+		SecurityToken: bbu.sessionToken,
 	}
 
 	return json.Marshal(bbuResponse)
 }
 
 func (bbu *BBU) conditionForKey(key string) string {
-	for i := range bbu.Conditions {
-		v, ok := bbu.Conditions[i][key]
-		if ok {
+	for _, condition := range bbu.Conditions {
+		m, ok := condition.(map[string]string)
+		if !ok {
+			continue
+		}
+
+		if v, ok := m[key]; ok {
 			return v
 		}
 	}
@@ -244,18 +404,31 @@ func (bbu *BBU) toParams() *bbuParams {
 		Conditions: bbu.Conditions,
 		Expiration: bbu.expiration,
 		Date:       bbu.dateString,
-		SecretKey:  bbu.config.SecretKey,
-		Region:     bbu.config.Region,
+		SecretKey:  bbu.secretKey,
+		Region:     bbu.region,
 	}
 }
 
 func (bbu *BBU) bucketURL() string {
-	s3s := ".s3"
-	if bbu.config.Accelerate {
-		s3s = ".s3-accelerate"
+	if bbu.config.Endpoint == "" {
+		s3s := ".s3"
+		if bbu.config.Accelerate {
+			s3s = ".s3-accelerate"
+		}
+
+		return "http://" + bbu.config.Bucket + s3s + ".amazonaws.com/"
+	}
+
+	scheme := "https"
+	if bbu.config.DisableSSL {
+		scheme = "http"
+	}
+
+	if bbu.config.PathStyle {
+		return scheme + "://" + bbu.config.Endpoint + "/" + bbu.config.Bucket + "/"
 	}
 
-	return "http://" + bbu.config.Bucket + s3s + ".amazonaws.com/"
+	return scheme + "://" + bbu.config.Bucket + "." + bbu.config.Endpoint + "/"
 }
 
 // Policy returns base64 policy from conditions, s3 config and date set on BBU.
@@ -291,6 +464,7 @@ func (bbu *bbuParams) toSignature() string {
 	return hmacToHex(signingKey, bbu.Policy64)
 }
 
-func (c *S3Config) credential(dateString string) string {
-	return strings.Join([]string{c.AccessKey, dateString, c.Region, fs4s.CredentialScope}, "/")
+// buildCredential joins the credential scope components S3 expects in x-amz-credential.
+func buildCredential(accessKey, dateString, region string) string {
+	return strings.Join([]string{accessKey, dateString, region, fs4s.CredentialScope}, "/")
 }
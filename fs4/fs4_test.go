@@ -0,0 +1,71 @@
+package fs4
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	fs4s "github.com/pJes2/fs4/fs4strings"
+)
+
+// TestBBUPolicyRendersMixedConditions asserts that AddEq/AddStartsWith/AddContentLengthRange
+// render as the array-form matchers S3 expects (`["eq", ...]`, `["starts-with", ...]`,
+// `["content-length-range", ...]`) alongside the existing flat equality conditions.
+func TestBBUPolicyRendersMixedConditions(t *testing.T) {
+	fs4 := NewClient(&S3Config{
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "secret",
+		Bucket:    "example-bucket",
+		Region:    "us-east-1",
+	})
+
+	bbu := fs4.NewBBU(15)
+	bbu.AddEq(fs4s.Key, "uploads/user-42/abc.jpg")
+	bbu.AddStartsWith(fs4s.Key, "uploads/user-42/")
+	bbu.AddContentLengthRange(1024, 10485760)
+
+	raw, err := base64.StdEncoding.DecodeString(bbu.Policy())
+	if err != nil {
+		t.Fatalf("failed to decode policy: %v", err)
+	}
+
+	var decoded struct {
+		Conditions []interface{} `json:"conditions"`
+	}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal policy: %v", err)
+	}
+
+	var gotEq, gotStartsWith, gotRange bool
+	for _, condition := range decoded.Conditions {
+		arr, ok := condition.([]interface{})
+		if !ok {
+			continue
+		}
+
+		switch arr[0] {
+		case "eq":
+			if arr[1] == "$"+fs4s.Key && arr[2] == "uploads/user-42/abc.jpg" {
+				gotEq = true
+			}
+		case "starts-with":
+			if arr[1] == "$"+fs4s.Key && arr[2] == "uploads/user-42/" {
+				gotStartsWith = true
+			}
+		case "content-length-range":
+			if arr[1] == float64(1024) && arr[2] == float64(10485760) {
+				gotRange = true
+			}
+		}
+	}
+
+	if !gotEq {
+		t.Error("expected policy to contain an eq condition for key")
+	}
+	if !gotStartsWith {
+		t.Error("expected policy to contain a starts-with condition for key")
+	}
+	if !gotRange {
+		t.Error("expected policy to contain a content-length-range condition")
+	}
+}
@@ -0,0 +1,216 @@
+package fs4
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// MultipartOptions configures a multipart upload started via Uploader.StartMultipart.
+type MultipartOptions struct {
+	// TotalBytes is the known final size of the upload, if any, used to report
+	// Progress. Leave zero when the size isn't known ahead of time.
+	TotalBytes int64
+}
+
+// MultipartUpload represents an in-progress multipart upload whose parts can be sent
+// out of order, resumed after a crash, inspected for progress, or aborted.
+type MultipartUpload struct {
+	client   *s3.S3
+	bucket   string
+	key      string
+	uploadID string
+
+	mu         sync.Mutex
+	parts      []*s3.CompletedPart
+	bytesSent  int64
+	totalBytes int64
+}
+
+// multipartState is the JSON shape returned by MultipartUpload.State and accepted by
+// Uploader.ResumeMultipart.
+type multipartState struct {
+	Bucket     string              `json:"bucket"`
+	Key        string              `json:"key"`
+	UploadID   string              `json:"upload_id"`
+	Parts      []*s3.CompletedPart `json:"parts"`
+	BytesSent  int64               `json:"bytes_sent"`
+	TotalBytes int64               `json:"total_bytes"`
+}
+
+// StartMultipart initiates a multipart upload for key and returns a MultipartUpload that
+// parts can be uploaded to independently, including from separate processes.
+func (u *Uploader) StartMultipart(ctx context.Context, key, mimetype string, opts MultipartOptions) (*MultipartUpload, error) {
+	out, err := u.client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(u.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(mimetype),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultipartUpload{
+		client:     u.client,
+		bucket:     u.bucket,
+		key:        key,
+		uploadID:   *out.UploadId,
+		totalBytes: opts.TotalBytes,
+	}, nil
+}
+
+// ResumeMultipart rebuilds a MultipartUpload from a state blob previously returned by
+// MultipartUpload.State, so upload of the remaining parts can continue.
+func (u *Uploader) ResumeMultipart(state []byte) (*MultipartUpload, error) {
+	var s multipartState
+	if err := json.Unmarshal(state, &s); err != nil {
+		return nil, err
+	}
+
+	return &MultipartUpload{
+		client:     u.client,
+		bucket:     s.Bucket,
+		key:        s.Key,
+		uploadID:   s.UploadID,
+		parts:      s.Parts,
+		bytesSent:  s.BytesSent,
+		totalBytes: s.TotalBytes,
+	}, nil
+}
+
+// UploadPart uploads a single part. Parts may be uploaded out of order and from separate
+// processes as long as they share the same resumed state.
+func (m *MultipartUpload) UploadPart(ctx context.Context, partNumber int, r io.ReadSeeker) error {
+	size, err := seekerSize(r)
+	if err != nil {
+		return err
+	}
+
+	out, err := m.client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(m.bucket),
+		Key:        aws.String(m.key),
+		UploadId:   aws.String(m.uploadID),
+		PartNumber: aws.Int64(int64(partNumber)),
+		Body:       r,
+	})
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.parts = append(m.parts, &s3.CompletedPart{
+		ETag:       out.ETag,
+		PartNumber: aws.Int64(int64(partNumber)),
+	})
+	m.bytesSent += size
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Complete finalizes the multipart upload from the parts committed so far and returns
+// the resulting object location.
+func (m *MultipartUpload) Complete(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	parts := make([]*s3.CompletedPart, len(m.parts))
+	copy(parts, m.parts)
+	m.mu.Unlock()
+
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+
+	out, err := m.client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(m.bucket),
+		Key:      aws.String(m.key),
+		UploadId: aws.String(m.uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{
+			Parts: parts,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return *out.Location, nil
+}
+
+// Abort cancels the multipart upload and discards any parts already committed.
+func (m *MultipartUpload) Abort(ctx context.Context) error {
+	_, err := m.client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(m.bucket),
+		Key:      aws.String(m.key),
+		UploadId: aws.String(m.uploadID),
+	})
+
+	return err
+}
+
+// Progress returns the number of bytes sent so far and the total size of the upload, if
+// known.
+func (m *MultipartUpload) Progress() (bytesSent, totalBytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.bytesSent, m.totalBytes
+}
+
+// State returns a serializable snapshot of the upload (bucket, key, upload ID and
+// committed parts) that Uploader.ResumeMultipart can later rebuild from.
+func (m *MultipartUpload) State() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return json.Marshal(&multipartState{
+		Bucket:     m.bucket,
+		Key:        m.key,
+		UploadID:   m.uploadID,
+		Parts:      m.parts,
+		BytesSent:  m.bytesSent,
+		TotalBytes: m.totalBytes,
+	})
+}
+
+// ListMultipartUploads lists in-progress multipart uploads for bucket so orphaned
+// uploads can be identified and reaped.
+func ListMultipartUploads(ctx context.Context, bucket string, s3Config *S3Config) ([]*s3.MultipartUpload, error) {
+	svc := prepareSVC(s3Config)
+
+	var uploads []*s3.MultipartUpload
+	err := svc.ListMultipartUploadsPagesWithContext(ctx, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(bucket),
+	}, func(page *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+		uploads = append(uploads, page.Uploads...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return uploads, nil
+}
+
+// seekerSize returns the number of bytes remaining to be read from r, leaving its
+// position unchanged.
+func seekerSize(r io.ReadSeeker) (int64, error) {
+	current, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := r.Seek(current, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return end - current, nil
+}
@@ -0,0 +1,99 @@
+package fs4
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	minPresignExpiry = time.Second
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// ErrInvalidExpiry is returned when a presign expiry falls outside the SigV4-allowed
+// range of 1 second to 7 days.
+var ErrInvalidExpiry = errors.New("fs4: presign expiry must be between 1s and 7 days")
+
+// Presigner represents object used to generate presigned S3 URLs decoupled from upload.
+type Presigner struct {
+	bucket string
+	client *s3.S3
+}
+
+// NewPresigner initializes and returns a new Presigner.
+func (fs4 *FS4) NewPresigner() *Presigner {
+	return &Presigner{
+		bucket: fs4.config.Bucket,
+		client: prepareSVC(fs4.config),
+	}
+}
+
+// PresignGet returns a presigned URL for a GET of key, valid for expiry. reqParams, when
+// set, are applied to the URL's query string so callers can override response headers
+// such as response-content-disposition or response-content-type.
+func (p *Presigner) PresignGet(key string, expiry time.Duration, reqParams url.Values) (string, error) {
+	if err := validateExpiry(expiry); err != nil {
+		return "", err
+	}
+
+	req, _ := p.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(key),
+	})
+
+	if reqParams != nil {
+		req.HTTPRequest.URL.RawQuery = reqParams.Encode()
+	}
+
+	return req.Presign(expiry)
+}
+
+// PresignPut returns a presigned URL for a PUT of key, valid for expiry. headers, when
+// set, are applied to the request before signing so they become signed headers the
+// caller must send back unmodified.
+func (p *Presigner) PresignPut(key string, expiry time.Duration, headers http.Header) (string, error) {
+	if err := validateExpiry(expiry); err != nil {
+		return "", err
+	}
+
+	req, _ := p.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(key),
+	})
+
+	for name, values := range headers {
+		for _, value := range values {
+			req.HTTPRequest.Header.Add(name, value)
+		}
+	}
+
+	return req.Presign(expiry)
+}
+
+// PresignHead returns a presigned URL for a HEAD of key, valid for expiry.
+func (p *Presigner) PresignHead(key string, expiry time.Duration) (string, error) {
+	if err := validateExpiry(expiry); err != nil {
+		return "", err
+	}
+
+	req, _ := p.client.HeadObjectRequest(&s3.HeadObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(key),
+	})
+
+	return req.Presign(expiry)
+}
+
+// validateExpiry returns ErrInvalidExpiry unless expiry falls within the SigV4 limit.
+func validateExpiry(expiry time.Duration) error {
+	if expiry < minPresignExpiry || expiry > maxPresignExpiry {
+		return ErrInvalidExpiry
+	}
+
+	return nil
+}
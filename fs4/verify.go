@@ -0,0 +1,150 @@
+package fs4
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	fs4s "github.com/pJes2/fs4/fs4strings"
+)
+
+// ErrPolicyExpired is returned by Verify when the submitted policy's expiration has passed.
+var ErrPolicyExpired = errors.New("fs4: policy has expired")
+
+// ErrSignatureMismatch is returned by Verify when the submitted x-amz-signature does not
+// match the signature recomputed from the policy and the BBU's secret key.
+var ErrSignatureMismatch = errors.New("fs4: signature mismatch")
+
+// ErrConditionMismatch is returned by Verify when a submitted form value does not satisfy
+// its matching policy condition.
+type ErrConditionMismatch struct {
+	Field string
+}
+
+func (e *ErrConditionMismatch) Error() string {
+	return fmt.Sprintf("fs4: submitted value for %q does not satisfy policy condition", e.Field)
+}
+
+// decodedPolicy represents the JSON shape of a base64-decoded POST policy.
+type decodedPolicy struct {
+	Expiration string        `json:"expiration"`
+	Conditions []interface{} `json:"conditions"`
+}
+
+// Verify validates an incoming POST upload's form fields against the policy it carries:
+// the policy must not be expired, every submitted value must satisfy its matching
+// condition, and the submitted x-amz-signature must match the one recomputed from the
+// BBU's secret key, region and x-amz-date. contentLength is the actual size of the
+// uploaded file part in bytes, as read by the caller's multipart handler; a browser POST
+// never submits Content-Length as a form field, so it can't be sourced from form itself.
+// It is only checked against a content-length-range condition, if the policy has one.
+func (bbu *BBU) Verify(form url.Values, contentLength int64) error {
+	policy64 := form.Get(fs4s.Policy)
+
+	raw, err := base64.StdEncoding.DecodeString(policy64)
+	if err != nil {
+		return fmt.Errorf("fs4: invalid policy encoding: %w", err)
+	}
+
+	var policy decodedPolicy
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		return fmt.Errorf("fs4: invalid policy JSON: %w", err)
+	}
+
+	expiresAt, err := time.Parse("2006-01-02T15:04:05.000Z", policy.Expiration)
+	if err != nil {
+		return fmt.Errorf("fs4: invalid expiration format: %w", err)
+	}
+
+	if time.Now().After(expiresAt) {
+		return ErrPolicyExpired
+	}
+
+	// Validate the signature before trusting the decoded policy's structure: the
+	// conditions below are attacker-controlled until this point.
+	dateISO := form.Get(fs4s.XAMZDate)
+	if len(dateISO) < 8 {
+		return fmt.Errorf("fs4: invalid %s value", fs4s.XAMZDate)
+	}
+
+	dateKey := shmacSHA256("AWS4"+bbu.secretKey, dateISO[:8])
+	dateRegionKey := hmacSHA256(dateKey, bbu.region)
+	dateRegionServiceKey := hmacSHA256(dateRegionKey, "s3")
+	signingKey := hmacSHA256(dateRegionServiceKey, "aws4_request")
+	expectedSignature := hmacToHex(signingKey, policy64)
+
+	if !hmac.Equal([]byte(expectedSignature), []byte(form.Get(fs4s.XAMZSignature))) {
+		return ErrSignatureMismatch
+	}
+
+	for _, condition := range policy.Conditions {
+		if err := verifyCondition(form, contentLength, condition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyCondition checks a single decoded policy condition (either a flat equality map
+// or an array-form matcher) against the submitted form values and contentLength.
+func verifyCondition(form url.Values, contentLength int64, condition interface{}) error {
+	switch c := condition.(type) {
+	case map[string]interface{}:
+		for field, want := range c {
+			wantStr, _ := want.(string)
+			if form.Get(field) != wantStr {
+				return &ErrConditionMismatch{Field: field}
+			}
+		}
+
+		return nil
+	case []interface{}:
+		if len(c) < 3 {
+			return &ErrConditionMismatch{Field: "conditions"}
+		}
+
+		op, _ := c[0].(string)
+		switch op {
+		case "eq":
+			field := strings.TrimPrefix(fmt.Sprint(c[1]), "$")
+			want, _ := c[2].(string)
+			if form.Get(field) != want {
+				return &ErrConditionMismatch{Field: field}
+			}
+		case "starts-with":
+			field := strings.TrimPrefix(fmt.Sprint(c[1]), "$")
+			prefix, _ := c[2].(string)
+			if !strings.HasPrefix(form.Get(field), prefix) {
+				return &ErrConditionMismatch{Field: field}
+			}
+		case "content-length-range":
+			min, max := toInt64(c[1]), toInt64(c[2])
+			if contentLength < min || contentLength > max {
+				return &ErrConditionMismatch{Field: fs4s.ContentLength}
+			}
+		}
+
+		return nil
+	default:
+		return nil
+	}
+}
+
+// toInt64 converts a policy condition bound (decoded from JSON as float64, or already
+// an int64 when the condition was built in-process) to int64.
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case int64:
+		return n
+	default:
+		return 0
+	}
+}
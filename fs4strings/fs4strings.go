@@ -30,6 +30,8 @@ const (
 	Bucket = "bucket"
 	// Content-Type Content-Type
 	ContentType = "Content-Type"
+	// ContentLength Content-Length
+	ContentLength = "Content-Length"
 	// SuccessActionRedirect success_action_redirect
 	SuccessActionRedirect = "success_action_redirect"
 	// SuccessActionStatus success_action_status